@@ -22,6 +22,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -31,21 +32,28 @@ import (
 )
 
 type Maze struct {
-	rooms      [][]mazelib.Room
-	start      mazelib.Coordinate
-	end        mazelib.Coordinate
-	icarus     mazelib.Coordinate
-	StepsTaken int
+	rooms  [][]mazelib.Room
+	start  mazelib.Coordinate
+	end    mazelib.Coordinate
+	icarus mazelib.Coordinate
+	// stepsTaken is read from the /sessions and /oracle handlers while a
+	// move handler for the same session may be incrementing it
+	// concurrently, so it's accessed only through atomic ops.
+	stepsTaken int32
 }
 
-// Tracking the current maze being solved
+// StepsTaken returns how many moves Icarus has made in this maze so far.
+// Safe to call concurrently with MoveLeft/MoveRight/MoveUp/MoveDown.
+func (m *Maze) StepsTaken() int {
+	return int(atomic.LoadInt32(&m.stepsTaken))
+}
 
-// WARNING: This approach is not safe for concurrent use
-// This server is only intended to have a single client at a time
-// We would need a different and more complex approach if we wanted
-// concurrent connections than these simple package variables
-var currentMaze *Maze
-var scores []int
+// Tracking every maze currently being solved.
+//
+// Previously this was a single package-global *Maze and []int, which
+// only worked for one client at a time. sessionMgr gives each client its
+// own Maze, keyed by the session token /awake hands it back.
+var sessionMgr *SessionManager
 
 // Defining the daedalus command.
 // This will be called as 'laybrinth daedalus'
@@ -66,17 +74,38 @@ func init() {
 	rand.Seed(time.Now().UTC().UnixNano()) // need to initialize the seed
 	gin.SetMode(gin.ReleaseMode)
 
+	// maze-file and generator are also read by the dump and solve
+	// subcommands (see daedalus_io.go, oracle.go), so they must be
+	// PersistentFlags to be inherited rather than rejected as unknown.
+	daedalusCmd.PersistentFlags().String("maze-file", "", "load the maze from this file instead of generating one")
+	viper.BindPFlag("maze-file", daedalusCmd.PersistentFlags().Lookup("maze-file"))
+
+	daedalusCmd.Flags().Int("max-sessions", 0, "maximum number of concurrent Icarus sessions (0 = unlimited)")
+	viper.BindPFlag("max-sessions", daedalusCmd.Flags().Lookup("max-sessions"))
+
+	daedalusCmd.Flags().Duration("session-timeout", 5*time.Minute, "how long an idle session is kept before it's cleaned up")
+	viper.BindPFlag("session-timeout", daedalusCmd.Flags().Lookup("session-timeout"))
+
+	daedalusCmd.PersistentFlags().String("generator", "", "maze generation algorithm: recursive-backtracker, kruskal, prim, recursive-division, eller, aldous-broder, wilsons, hunt-and-kill")
+	viper.BindPFlag("generator", daedalusCmd.PersistentFlags().Lookup("generator"))
+
 	RootCmd.AddCommand(daedalusCmd)
 }
 
 // Runs the web server
 func RunServer() {
+	sessionMgr = NewSessionManager(viper.GetInt("max-sessions"), viper.GetDuration("session-timeout"))
+
+	stop := make(chan struct{})
+	go sessionMgr.runExpiryLoop(time.Minute, stop)
+
 	// Adding handling so that even when ctrl+c is pressed we still print
 	// out the results prior to exiting.
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt)
 	go func() {
 		<-c
+		close(stop)
 		printResults()
 		os.Exit(1)
 	}()
@@ -88,6 +117,12 @@ func RunServer() {
 		v1.GET("/awake", GetStartingPoint)
 		v1.GET("/move/:direction", MoveDirection)
 		v1.GET("/done", End)
+		v1.GET("/stats", Stats)
+		v1.GET("/sessions", Sessions)
+		v1.GET("/ws", WS)
+		if viper.GetBool("debug") {
+			v1.GET("/oracle", Oracle)
+		}
 	}
 
 	r.Run(":" + viper.GetString("port"))
@@ -95,39 +130,56 @@ func RunServer() {
 
 // Ends a session and prints the results.
 // Called by Icarus when he has reached
-//   the number of times he wants to solve the laybrinth.
+//
+//	the number of times he wants to solve the laybrinth.
+//
+// Other Icarus clients may still be racing in their own sessions, so this
+// only tears down the caller's session; it must not take the server down.
 func End(c *gin.Context) {
+	sessionMgr.Delete(c.GetHeader(SessionHeader))
 	printResults()
-	os.Exit(1)
 }
 
-// initializes a new maze and places Icarus in his awakening location
+// initializes a new maze, places Icarus in his awakening location, and
+// returns the session token Icarus must use for every subsequent request.
 func GetStartingPoint(c *gin.Context) {
-	initializeMaze()
-	startRoom, err := currentMaze.Discover(currentMaze.Icarus())
+	session, err := sessionMgr.Create()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, mazelib.Reply{Error: true, Message: err.Error()})
+		return
+	}
+
+	startRoom, err := session.Maze.Discover(session.Maze.Icarus())
 	if err != nil {
 		fmt.Println("Icarus is outside of the maze. This shouldn't ever happen")
 		fmt.Println(err)
 		os.Exit(-1)
 	}
-	mazelib.PrintMaze(currentMaze)
+	mazelib.PrintMaze(session.Maze)
 
-	c.JSON(http.StatusOK, mazelib.Reply{Survey: startRoom})
+	c.JSON(http.StatusOK, awakeReply{
+		Reply:   mazelib.Reply{Survey: startRoom},
+		Session: session.Token,
+	})
 }
 
 // The API response to the /move/:direction address
 func MoveDirection(c *gin.Context) {
-	var err error
+	session, err := sessionMgr.Get(c.GetHeader(SessionHeader))
+	if err != nil {
+		c.JSON(http.StatusNotFound, mazelib.Reply{Error: true, Message: err.Error()})
+		return
+	}
 
 	switch c.Param("direction") {
 	case "left":
-		err = currentMaze.MoveLeft()
+		err = session.Maze.MoveLeft()
 	case "right":
-		err = currentMaze.MoveRight()
+		err = session.Maze.MoveRight()
 	case "down":
-		err = currentMaze.MoveDown()
+		err = session.Maze.MoveDown()
 	case "up":
-		err = currentMaze.MoveUp()
+		err = session.Maze.MoveUp()
 	}
 
 	var r mazelib.Reply
@@ -139,13 +191,13 @@ func MoveDirection(c *gin.Context) {
 		return
 	}
 
-	s, e := currentMaze.LookAround()
+	s, e := session.Maze.LookAround()
 
 	if e != nil {
 		if e == mazelib.ErrVictory {
-			scores = append(scores, currentMaze.StepsTaken)
+			sessionMgr.Complete(session.Token, session.Maze.StepsTaken())
 			r.Victory = true
-			r.Message = fmt.Sprintf("Victory achieved in %d steps \n", currentMaze.StepsTaken)
+			r.Message = fmt.Sprintf("Victory achieved in %d steps \n", session.Maze.StepsTaken())
 		} else {
 			r.Error = true
 			r.Message = err.Error()
@@ -157,13 +209,28 @@ func MoveDirection(c *gin.Context) {
 	c.JSON(http.StatusOK, r)
 }
 
-func initializeMaze() {
-	currentMaze = createMaze()
+// Stats reports aggregate statistics across every active session.
+func Stats(c *gin.Context) {
+	c.JSON(http.StatusOK, sessionMgr.Stats())
+}
+
+// Sessions lists every active session, mostly useful for debugging a
+// tournament with many concurrent Icarus clients.
+func Sessions(c *gin.Context) {
+	c.JSON(http.StatusOK, sessionMgr.List())
+}
+
+// awakeReply is mazelib.Reply plus the session token the client must
+// attach to every subsequent request.
+type awakeReply struct {
+	mazelib.Reply
+	Session string `json:"session"`
 }
 
 // Print to the terminal the average steps to solution for the current session
 func printResults() {
-	fmt.Printf("Labyrinth solved %d times with an avg of %d steps\n", len(scores), mazelib.AvgScores(scores))
+	stats := sessionMgr.Stats()
+	fmt.Printf("Labyrinth solved %d times with an avg of %d steps\n", stats.TotalCompleted, stats.AvgSteps)
 }
 
 // Return a room from the maze
@@ -221,7 +288,7 @@ func (m *Maze) SetTreasure(x, y int) error {
 // Will return ErrVictory if Icarus is at the treasure.
 func (m *Maze) LookAround() (mazelib.Survey, error) {
 	if m.end.X == m.icarus.X && m.end.Y == m.icarus.Y {
-		fmt.Printf("Victory achieved in %d steps \n", m.StepsTaken)
+		fmt.Printf("Victory achieved in %d steps \n", m.StepsTaken())
 		return mazelib.Survey{}, mazelib.ErrVictory
 	}
 
@@ -257,7 +324,7 @@ func (m *Maze) MoveLeft() error {
 	}
 
 	m.icarus = mazelib.Coordinate{x - 1, y}
-	m.StepsTaken++
+	atomic.AddInt32(&m.stepsTaken, 1)
 	return nil
 }
 
@@ -278,7 +345,7 @@ func (m *Maze) MoveRight() error {
 	}
 
 	m.icarus = mazelib.Coordinate{x + 1, y}
-	m.StepsTaken++
+	atomic.AddInt32(&m.stepsTaken, 1)
 	return nil
 }
 
@@ -299,7 +366,7 @@ func (m *Maze) MoveUp() error {
 	}
 
 	m.icarus = mazelib.Coordinate{x, y - 1}
-	m.StepsTaken++
+	atomic.AddInt32(&m.stepsTaken, 1)
 	return nil
 }
 
@@ -320,7 +387,7 @@ func (m *Maze) MoveDown() error {
 	}
 
 	m.icarus = mazelib.Coordinate{x, y + 1}
-	m.StepsTaken++
+	atomic.AddInt32(&m.stepsTaken, 1)
 	return nil
 }
 
@@ -358,16 +425,89 @@ func fullMaze() *Maze {
 	return z
 }
 
+// Maximum number of times createMaze will regenerate a maze that falls
+// below --min-difficulty before giving up and using the last attempt.
+const maxDifficultyAttempts = 20
+
 // TODO: Write your maze creator function here
 func createMaze() *Maze {
+	if path := viper.GetString("maze-file"); path != "" {
+		m, err := loadMazeFile(path)
+		if err != nil {
+			fmt.Println("Unable to load --maze-file:", err)
+			os.Exit(-1)
+		}
+		return m
+	}
 
-	// TODO: Fill in the maze:
-	// You need to insert a startingPoint for Icarus
-	// You need to insert an EndingPoint (treasure) for Icarus
-	// You need to Add and Remove walls as needed.
-	// Use the mazelib.AddWall & mazelib.RmWall to do this
+	minDifficulty := viper.GetFloat64("min-difficulty")
 
-	return emptyMaze()
+	var m *Maze
+	for attempt := 0; attempt < maxDifficultyAttempts; attempt++ {
+		m = generateMaze()
+		if minDifficulty <= 0 {
+			break
+		}
+		if m.Difficulty().SolvabilityScore >= minDifficulty {
+			break
+		}
+	}
+
+	return m
+}
+
+// generateMaze builds a fresh maze using whichever --generator algorithm
+// is configured, then places Icarus and the treasure at opposite
+// corners so the maze is actually playable.
+func generateMaze() *Maze {
+	m := generateLayout()
+
+	x, y := m.Width()-1, m.Height()-1
+	if err := m.SetStartPoint(0, 0); err != nil {
+		fmt.Println("Unable to set start point:", err)
+		os.Exit(-1)
+	}
+	if x == 0 && y == 0 {
+		// A 1x1 maze can't have a treasure room distinct from the start.
+		return m
+	}
+	if err := m.SetTreasure(x, y); err != nil {
+		fmt.Println("Unable to set treasure:", err)
+		os.Exit(-1)
+	}
+
+	return m
+}
+
+// generateLayout carves the passages for whichever --generator algorithm
+// is configured, without touching start/treasure.
+func generateLayout() *Maze {
+	switch viper.GetString("generator") {
+	case "recursive-backtracker":
+		m := fullMaze()
+		m.carvePassagesFrom(0, 0)
+		return m
+	case "kruskal":
+		m := fullMaze()
+		m.kruskal()
+		return m
+	case "prim":
+		return prim()
+	case "recursive-division":
+		return recursiveDivision()
+	case "eller":
+		return eller()
+	case "aldous-broder":
+		return aldousBroder()
+	case "wilsons":
+		return wilsons()
+	case "hunt-and-kill":
+		return huntAndKill()
+	default:
+		m := fullMaze()
+		m.carvePassagesFrom(0, 0)
+		return m
+	}
 }
 
 // MY SOLUTIONS
@@ -422,11 +562,250 @@ func (m *Maze) carvePassagesFrom(x, y int) {
 
 // Eller's Algorithm
 // http://weblog.jamisbuck.org/2010/12/29/maze-generation-eller-s-algorithm.html
-type state struct {
-	width   int
-	nextSet int
-	sets    map[string][]string
-	cells   map[string]map[string][]string
+//
+// Builds the maze one row at a time. Every cell starts in its own set;
+// within a row, adjacent cells in different sets are randomly merged
+// (always merged on the last row, so it ends up fully connected), then
+// each set carves at least one passage down into the next row, and the
+// cells it carves into inherit that set.
+func eller() *Maze {
+	m := fullMaze()
+	w, h := m.Width(), m.Height()
+
+	nextSet := 0
+	row := make([]int, w)
+	for x := range row {
+		row[x] = nextSet
+		nextSet++
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			if row[x] == row[x+1] {
+				continue
+			}
+			if y < h-1 && rand.Intn(2) == 0 {
+				continue
+			}
+
+			oldSet, newSet := row[x+1], row[x]
+			for i, s := range row {
+				if s == oldSet {
+					row[i] = newSet
+				}
+			}
+
+			cr, _ := m.GetRoom(x, y)
+			nr, _ := m.GetRoom(x+1, y)
+			cr.RmWall(E)
+			nr.RmWall(W)
+		}
+
+		if y == h-1 {
+			break
+		}
+
+		bySet := make(map[int][]int)
+		for x, s := range row {
+			bySet[s] = append(bySet[s], x)
+		}
+
+		next := make([]int, w)
+		for x := range next {
+			next[x] = -1
+		}
+
+		for s, xs := range bySet {
+			perm := rand.Perm(len(xs))
+			carved := 1 + rand.Intn(len(xs))
+			for _, i := range perm[:carved] {
+				x := xs[i]
+				cr, _ := m.GetRoom(x, y)
+				nr, _ := m.GetRoom(x, y+1)
+				cr.RmWall(S)
+				nr.RmWall(N)
+				next[x] = s
+			}
+		}
+
+		for x := range next {
+			if next[x] == -1 {
+				next[x] = nextSet
+				nextSet++
+			}
+		}
+
+		row = next
+	}
+
+	return m
+}
+
+// Aldous-Broder Algorithm
+// A uniform spanning tree algorithm: walk to a uniformly random neighbor
+// at each step, and whenever that neighbor hasn't been visited yet,
+// carve a passage into it. Finishes once every cell has been visited.
+func aldousBroder() *Maze {
+	m := fullMaze()
+	w, h := m.Width(), m.Height()
+
+	x, y := rand.Intn(w), rand.Intn(h)
+	visited := map[mazelib.Coordinate]bool{{X: x, Y: y}: true}
+	remaining := w*h - 1
+
+	for remaining > 0 {
+		d := DIRECTIONS[rand.Intn(len(DIRECTIONS))]
+		nx, ny := x+DX[d], y+DY[d]
+		if _, err := m.GetRoom(nx, ny); err != nil {
+			continue
+		}
+
+		np := mazelib.Coordinate{X: nx, Y: ny}
+		if !visited[np] {
+			cr, _ := m.GetRoom(x, y)
+			nr, _ := m.GetRoom(nx, ny)
+			cr.RmWall(d)
+			nr.RmWall(OPPOSITE[d])
+			visited[np] = true
+			remaining--
+		}
+
+		x, y = nx, ny
+	}
+
+	return m
+}
+
+// Wilson's Algorithm
+// Also produces a uniform spanning tree, but via loop-erased random
+// walks: walk randomly from an unvisited cell until hitting the
+// already-carved tree, erasing any loop the walk makes along the way,
+// then carve the surviving path into the maze.
+func wilsons() *Maze {
+	m := fullMaze()
+	w, h := m.Width(), m.Height()
+
+	inTree := map[mazelib.Coordinate]bool{
+		{X: rand.Intn(w), Y: rand.Intn(h)}: true,
+	}
+
+	for len(inTree) < w*h {
+		var start mazelib.Coordinate
+		for {
+			start = mazelib.Coordinate{X: rand.Intn(w), Y: rand.Intn(h)}
+			if !inTree[start] {
+				break
+			}
+		}
+
+		index := map[mazelib.Coordinate]int{start: 0}
+		walk := []mazelib.Coordinate{start}
+		cur := start
+
+		for !inTree[cur] {
+			d := DIRECTIONS[rand.Intn(len(DIRECTIONS))]
+			nx, ny := cur.X+DX[d], cur.Y+DY[d]
+			if _, err := m.GetRoom(nx, ny); err != nil {
+				continue
+			}
+			np := mazelib.Coordinate{X: nx, Y: ny}
+
+			if i, ok := index[np]; ok {
+				// The walk looped back on itself: erase the loop.
+				walk = walk[:i+1]
+				for c, idx := range index {
+					if idx > i {
+						delete(index, c)
+					}
+				}
+				cur = np
+				continue
+			}
+
+			index[np] = len(walk)
+			walk = append(walk, np)
+			cur = np
+		}
+
+		for i := 0; i < len(walk)-1; i++ {
+			a, b := walk[i], walk[i+1]
+			d := direction(a.X, a.Y, b.X, b.Y)
+			ra, _ := m.GetRoom(a.X, a.Y)
+			rb, _ := m.GetRoom(b.X, b.Y)
+			ra.RmWall(d)
+			rb.RmWall(OPPOSITE[d])
+			inTree[a] = true
+		}
+		inTree[walk[len(walk)-1]] = true
+	}
+
+	return m
+}
+
+// Hunt-and-Kill Algorithm
+// Like the recursive backtracker, but without a stack: walk randomly,
+// carving into unvisited neighbors, until stuck. Then scan the grid for
+// the first unvisited cell next to a visited one, carve into it, and
+// resume walking from there. Finishes once the hunt finds nothing.
+func huntAndKill() *Maze {
+	m := fullMaze()
+	w, h := m.Width(), m.Height()
+
+	x, y := rand.Intn(w), rand.Intn(h)
+	visited := map[mazelib.Coordinate]bool{{X: x, Y: y}: true}
+
+	for {
+		moved := false
+		for _, i := range rand.Perm(len(DIRECTIONS)) {
+			d := DIRECTIONS[i]
+			nx, ny := x+DX[d], y+DY[d]
+			if _, err := m.GetRoom(nx, ny); err != nil {
+				continue
+			}
+			if visited[mazelib.Coordinate{X: nx, Y: ny}] {
+				continue
+			}
+
+			cr, _ := m.GetRoom(x, y)
+			nr, _ := m.GetRoom(nx, ny)
+			cr.RmWall(d)
+			nr.RmWall(OPPOSITE[d])
+			visited[mazelib.Coordinate{X: nx, Y: ny}] = true
+			x, y = nx, ny
+			moved = true
+			break
+		}
+		if moved {
+			continue
+		}
+
+		hunted := false
+		for hy := 0; hy < h && !hunted; hy++ {
+			for hx := 0; hx < w && !hunted; hx++ {
+				if visited[mazelib.Coordinate{X: hx, Y: hy}] {
+					continue
+				}
+				for _, d := range DIRECTIONS {
+					nx, ny := hx+DX[d], hy+DY[d]
+					if !visited[mazelib.Coordinate{X: nx, Y: ny}] {
+						continue
+					}
+
+					cr, _ := m.GetRoom(hx, hy)
+					nr, _ := m.GetRoom(nx, ny)
+					cr.RmWall(d)
+					nr.RmWall(OPPOSITE[d])
+					visited[mazelib.Coordinate{X: hx, Y: hy}] = true
+					x, y = hx, hy
+					hunted = true
+					break
+				}
+			}
+		}
+		if !hunted {
+			return m
+		}
+	}
 }
 
 // Kruskal's Algorithm
@@ -511,7 +890,7 @@ func (m *Maze) neighbors(x, y int) map[*mazelib.Room][]int {
 }
 
 func prim() *Maze {
-	m := emptyMaze()
+	m := fullMaze()
 	// frontier
 	frontiers := make(map[*mazelib.Room][]int)
 	// connected rooms
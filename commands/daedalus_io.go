@@ -0,0 +1,145 @@
+// Copyright © 2015 Steve Francia <spf@spf13.com>.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+//
+
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/isaiah/gc6/mazelib"
+	mazeio "github.com/isaiah/gc6/mazelib/io"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// dumpCmd emits the maze Daedalus would otherwise only serve over HTTP,
+// so it can be inspected, shared, or saved as a golden file.
+var dumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Generate a maze and print it to stdout",
+	Long: `dump generates a maze exactly the way 'daedalus' would and prints
+  it to stdout instead of starting the server, in either the ASCII grid
+  or JSON format.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		m := createMaze()
+		data := m.toMazeData()
+
+		var (
+			raw []byte
+			err error
+		)
+		if viper.GetString("format") == "json" {
+			raw, err = data.MarshalJSON()
+		} else {
+			raw, err = data.MarshalText()
+		}
+		if err != nil {
+			fmt.Println("Unable to dump maze:", err)
+			os.Exit(-1)
+		}
+
+		os.Stdout.Write(raw)
+		os.Stdout.Write([]byte("\n"))
+	},
+}
+
+func init() {
+	dumpCmd.Flags().String("format", "text", "output format: text or json")
+	viper.BindPFlag("format", dumpCmd.Flags().Lookup("format"))
+
+	daedalusCmd.AddCommand(dumpCmd)
+}
+
+// toMazeData converts a Maze into the serializable shape mazelib/io knows
+// how to write to disk.
+func (m *Maze) toMazeData() *mazeio.MazeData {
+	d := &mazeio.MazeData{
+		Width:    m.Width(),
+		Height:   m.Height(),
+		Start:    m.start,
+		Treasure: m.end,
+	}
+
+	d.Walls = make([][]uint8, m.Height())
+	for y := 0; y < m.Height(); y++ {
+		d.Walls[y] = make([]uint8, m.Width())
+		for x := 0; x < m.Width(); x++ {
+			r, _ := m.GetRoom(x, y)
+			d.Walls[y][x] = wallBitmask(r.Walls)
+		}
+	}
+
+	return d
+}
+
+// fromMazeData builds a Maze from a previously saved mazelib/io.MazeData,
+// restoring its walls, start, and treasure. Returns an error if the file's
+// start/treasure coordinates are out of range or otherwise invalid, e.g.
+// a corrupt or hand-edited file.
+func fromMazeData(d *mazeio.MazeData) (*Maze, error) {
+	m := &Maze{rooms: make([][]mazelib.Room, d.Height)}
+	for y := 0; y < d.Height; y++ {
+		m.rooms[y] = make([]mazelib.Room, d.Width)
+		for x := 0; x < d.Width; x++ {
+			m.rooms[y][x].Walls = surveyFromBitmask(d.Walls[y][x])
+		}
+	}
+
+	if err := m.SetStartPoint(d.Start.X, d.Start.Y); err != nil {
+		return nil, fmt.Errorf("maze file: invalid start: %w", err)
+	}
+	m.start = d.Start
+	if err := m.SetTreasure(d.Treasure.X, d.Treasure.Y); err != nil {
+		return nil, fmt.Errorf("maze file: invalid treasure: %w", err)
+	}
+	return m, nil
+}
+
+// loadMazeFile reads a maze previously saved with mazelib/io.SaveMaze (or
+// the `daedalus dump` subcommand) and turns it into a playable Maze.
+func loadMazeFile(path string) (*Maze, error) {
+	d, err := mazeio.LoadMaze(path)
+	if err != nil {
+		return nil, err
+	}
+	return fromMazeData(d)
+}
+
+func wallBitmask(s mazelib.Survey) uint8 {
+	var b uint8
+	if s.Top {
+		b |= mazeio.WallNorth
+	}
+	if s.Right {
+		b |= mazeio.WallEast
+	}
+	if s.Bottom {
+		b |= mazeio.WallSouth
+	}
+	if s.Left {
+		b |= mazeio.WallWest
+	}
+	return b
+}
+
+func surveyFromBitmask(b uint8) mazelib.Survey {
+	return mazelib.Survey{
+		Top:    b&mazeio.WallNorth != 0,
+		Right:  b&mazeio.WallEast != 0,
+		Bottom: b&mazeio.WallSouth != 0,
+		Left:   b&mazeio.WallWest != 0,
+	}
+}
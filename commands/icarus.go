@@ -0,0 +1,350 @@
+// Copyright © 2015 Steve Francia <spf@spf13.com>.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+//
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/isaiah/gc6/mazelib"
+	"github.com/isaiah/gc6/mazelib/solver"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Defining the icarus command.
+// This will be called as 'laybrinth icarus'
+var icarusCmd = &cobra.Command{
+	Use:     "icarus",
+	Aliases: []string{"client"},
+	Short:   "Start the laybrinth solver",
+	Long: `Icarus wakes up somewhere in the Labyrinth and has to find the
+  treasure. He connects to a running Daedalus server, surveys his
+  surroundings after every move and decides where to go next using
+  whichever --solver strategy was requested.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		RunIcarus()
+	},
+}
+
+func init() {
+	icarusCmd.PersistentFlags().StringP("solver", "s", "wallfollower", "solver strategy: wallfollower, tremaux, bfs, astar")
+	icarusCmd.PersistentFlags().IntP("times", "n", 1, "number of times to solve the maze before quitting")
+	icarusCmd.PersistentFlags().String("transport", "auto", "transport to use: auto, rest, ws")
+	viper.BindPFlag("solver", icarusCmd.PersistentFlags().Lookup("solver"))
+	viper.BindPFlag("times", icarusCmd.PersistentFlags().Lookup("times"))
+	viper.BindPFlag("transport", icarusCmd.PersistentFlags().Lookup("transport"))
+
+	RootCmd.AddCommand(icarusCmd)
+	icarusCmd.AddCommand(benchmarkCmd)
+}
+
+// directionPath maps an internal direction constant to the URL segment
+// Daedalus's /move/:direction route expects.
+var directionPath = map[int]string{
+	N: "up",
+	S: "down",
+	E: "right",
+	W: "left",
+}
+
+// newSolver builds the solver.Solver requested via --solver. It defaults
+// to the wall-follower when given an unrecognized name.
+func newSolver(name string) solver.Solver {
+	switch name {
+	case "tremaux":
+		return solver.NewTremaux()
+	case "bfs":
+		return solver.NewBFS()
+	case "astar":
+		return solver.NewAStar()
+	default:
+		return solver.NewWallFollower()
+	}
+}
+
+// RunIcarus drives the solve loop against a running Daedalus server the
+// number of times requested by --times, printing per-run stats at the end.
+func RunIcarus() {
+	t, transportName := dialTransport(viper.GetString("transport"), viper.GetString("port"))
+	defer t.Close()
+	fmt.Println("Using transport:", transportName)
+
+	times := viper.GetInt("times")
+
+	var runs []int
+	var lastSession string
+	for i := 0; i < times; i++ {
+		session, steps, err := solveOnce(t, viper.GetString("solver"))
+		if err != nil {
+			fmt.Println("Icarus failed to solve the maze:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Run %d: solved in %d steps\n", i+1, steps)
+		runs = append(runs, steps)
+		lastSession = session
+	}
+
+	printRunResults(runs)
+	t.Done(lastSession)
+}
+
+// transport is however Icarus happens to be talking to Daedalus this
+// run: the classic REST API, or the lower-latency /ws connection.
+type transport interface {
+	Awake() (session string, survey mazelib.Survey, err error)
+	Move(session, direction string) (mazelib.Reply, error)
+	Done(session string)
+	Close()
+}
+
+// dialTransport honors --transport: "rest" or "ws" use exactly that
+// transport, "auto" prefers ws and falls back to REST if the server
+// doesn't support it.
+func dialTransport(name, port string) (transport, string) {
+	base := "http://localhost:" + port
+
+	switch name {
+	case "rest":
+		return &restTransport{base: base}, "rest"
+	case "ws":
+		ws, err := newWSTransport(port)
+		if err != nil {
+			fmt.Println("Unable to dial --transport=ws:", err)
+			os.Exit(1)
+		}
+		return ws, "ws"
+	default:
+		if ws, err := newWSTransport(port); err == nil {
+			return ws, "ws"
+		}
+		return &restTransport{base: base}, "rest"
+	}
+}
+
+// solveOnce wakes Icarus up, solves the maze once, and returns the
+// session token it used along with the number of steps it took.
+func solveOnce(t transport, solverName string) (string, int, error) {
+	s := newSolver(solverName)
+
+	session, survey, err := t.Awake()
+	if err != nil {
+		return "", 0, err
+	}
+
+	pos := mazelib.Coordinate{X: 0, Y: 0}
+	steps := 0
+
+	// A buggy or non-terminating solver strategy must fail cleanly
+	// instead of hanging Icarus forever.
+	maxSteps := viper.GetInt("width") * viper.GetInt("height") * 4
+	if maxSteps <= 0 {
+		maxSteps = 1000
+	}
+
+	for {
+		if steps >= maxSteps {
+			return session, steps, fmt.Errorf("solver %q did not reach the treasure within %d steps", solverName, maxSteps)
+		}
+
+		d := s.NextMove(survey, pos)
+		reply, err := t.Move(session, directionPath[d])
+		if err != nil {
+			return session, steps, err
+		}
+
+		steps++
+		pos = mazelib.Coordinate{X: pos.X + DX[d], Y: pos.Y + DY[d]}
+
+		if reply.Victory {
+			return session, steps, nil
+		}
+		if reply.Error {
+			return session, steps, fmt.Errorf("daedalus: %s", reply.Message)
+		}
+
+		survey = reply.Survey
+	}
+}
+
+// restTransport is the original HTTP request-per-move implementation.
+type restTransport struct {
+	base string
+}
+
+func (t *restTransport) Awake() (string, mazelib.Survey, error) {
+	resp, err := http.Get(t.base + "/awake")
+	if err != nil {
+		return "", mazelib.Survey{}, err
+	}
+	defer resp.Body.Close()
+
+	var r awakeReply
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", mazelib.Survey{}, err
+	}
+	return r.Session, r.Survey, nil
+}
+
+func (t *restTransport) Move(session, direction string) (mazelib.Reply, error) {
+	req, err := http.NewRequest("GET", t.base+"/move/"+direction, nil)
+	if err != nil {
+		return mazelib.Reply{}, err
+	}
+	req.Header.Set(SessionHeader, session)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return mazelib.Reply{}, err
+	}
+	defer resp.Body.Close()
+
+	var r mazelib.Reply
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return mazelib.Reply{}, err
+	}
+	return r, nil
+}
+
+func (t *restTransport) Done(session string) {
+	req, err := http.NewRequest("GET", t.base+"/done", nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set(SessionHeader, session)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+func (t *restTransport) Close() {}
+
+// wsTransport drives the same solve loop over a single persistent /ws
+// connection, paying one dial instead of an HTTP round trip per move.
+type wsTransport struct {
+	conn *websocket.Conn
+}
+
+func newWSTransport(port string) (*wsTransport, error) {
+	url := "ws://localhost:" + port + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &wsTransport{conn: conn}, nil
+}
+
+func (t *wsTransport) Awake() (string, mazelib.Survey, error) {
+	if err := t.conn.WriteJSON(wsCommand{Cmd: "awake"}); err != nil {
+		return "", mazelib.Survey{}, err
+	}
+
+	var f wsFrame
+	if err := t.conn.ReadJSON(&f); err != nil {
+		return "", mazelib.Survey{}, err
+	}
+	if f.Error != "" {
+		return "", mazelib.Survey{}, fmt.Errorf("daedalus: %s", f.Error)
+	}
+	// The /ws protocol keeps session state on the connection itself, so
+	// there's no token to hand back; the session string is unused by
+	// wsTransport.Move but kept in the transport interface for parity
+	// with restTransport.
+	return "ws", f.Survey, nil
+}
+
+func (t *wsTransport) Move(session, direction string) (mazelib.Reply, error) {
+	if err := t.conn.WriteJSON(wsCommand{Cmd: "move", Dir: direction}); err != nil {
+		return mazelib.Reply{}, err
+	}
+
+	var f wsFrame
+	if err := t.conn.ReadJSON(&f); err != nil {
+		return mazelib.Reply{}, err
+	}
+	if f.Error != "" {
+		return mazelib.Reply{Error: true, Message: f.Error}, nil
+	}
+
+	return mazelib.Reply{Survey: f.Survey, Victory: f.Victory}, nil
+}
+
+func (t *wsTransport) Done(session string) {}
+
+func (t *wsTransport) Close() {
+	t.conn.Close()
+}
+
+// benchmarkCmd compares the REST and websocket transports by running
+// the same number of solves over each and reporting how long it took.
+var benchmarkCmd = &cobra.Command{
+	Use:   "benchmark",
+	Short: "Compare the REST and ws transports over N solves",
+	Run: func(cmd *cobra.Command, args []string) {
+		times := viper.GetInt("times")
+		if times <= 0 {
+			times = 1000
+		}
+		solverName := viper.GetString("solver")
+		port := viper.GetString("port")
+
+		restElapsed := benchmarkTransport(&restTransport{base: "http://localhost:" + port}, solverName, times)
+		fmt.Printf("rest: %d solves in %s\n", times, restElapsed)
+
+		ws, err := newWSTransport(port)
+		if err != nil {
+			fmt.Println("ws transport unavailable:", err)
+			return
+		}
+		wsElapsed := benchmarkTransport(ws, solverName, times)
+		fmt.Printf("ws:   %d solves in %s\n", times, wsElapsed)
+	},
+}
+
+func benchmarkTransport(t transport, solverName string, times int) time.Duration {
+	defer t.Close()
+
+	start := time.Now()
+	for i := 0; i < times; i++ {
+		if _, _, err := solveOnce(t, solverName); err != nil {
+			fmt.Println("benchmark run failed:", err)
+			break
+		}
+	}
+	return time.Since(start)
+}
+
+// printRunResults prints Icarus's own view of how each run went, which is
+// handy for comparing --solver strategies against each other.
+func printRunResults(runs []int) {
+	if len(runs) == 0 {
+		return
+	}
+
+	total := 0
+	for _, s := range runs {
+		total += s
+	}
+
+	fmt.Printf("Icarus solved %d times with an avg of %d steps\n", len(runs), total/len(runs))
+}
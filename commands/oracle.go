@@ -0,0 +1,214 @@
+// Copyright © 2015 Steve Francia <spf@spf13.com>.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+//
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/isaiah/gc6/mazelib"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ErrNoPath is returned by ShortestPath when there is no route between
+// the two coordinates given the maze's current walls.
+var ErrNoPath = errors.New("no path between the given rooms")
+
+// canStep reports whether Icarus is allowed to move from (x, y) in
+// direction d, using the same rule MoveLeft/MoveRight/MoveUp/MoveDown
+// apply: only the wall of the room he's leaving matters.
+func canStep(m *Maze, x, y, d int) bool {
+	r, err := m.GetRoom(x, y)
+	if err != nil {
+		return false
+	}
+	if _, err := m.GetRoom(x+DX[d], y+DY[d]); err != nil {
+		return false
+	}
+	switch d {
+	case N:
+		return !r.Walls.Top
+	case S:
+		return !r.Walls.Bottom
+	case E:
+		return !r.Walls.Right
+	case W:
+		return !r.Walls.Left
+	}
+	return false
+}
+
+// ShortestPath returns the shortest sequence of rooms from `from` to `to`,
+// walking only through open passages, found via a breadth-first search
+// over the wall-aware adjacency of the maze.
+func (m *Maze) ShortestPath(from, to mazelib.Coordinate) ([]mazelib.Coordinate, error) {
+	if from == to {
+		return []mazelib.Coordinate{from}, nil
+	}
+
+	type node struct {
+		pos  mazelib.Coordinate
+		path []mazelib.Coordinate
+	}
+
+	visited := map[mazelib.Coordinate]bool{from: true}
+	queue := []node{{pos: from, path: []mazelib.Coordinate{from}}}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		for _, d := range DIRECTIONS {
+			if !canStep(m, cur.pos.X, cur.pos.Y, d) {
+				continue
+			}
+			np := mazelib.Coordinate{X: cur.pos.X + DX[d], Y: cur.pos.Y + DY[d]}
+			if visited[np] {
+				continue
+			}
+			visited[np] = true
+
+			path := make([]mazelib.Coordinate, len(cur.path)+1)
+			copy(path, cur.path)
+			path[len(cur.path)] = np
+
+			if np == to {
+				return path, nil
+			}
+			queue = append(queue, node{pos: np, path: path})
+		}
+	}
+
+	return nil, ErrNoPath
+}
+
+// MazeStats describes how hard a generated maze is to solve.
+type MazeStats struct {
+	OptimalSteps     int     `json:"optimal_steps"`
+	DeadEnds         int     `json:"dead_ends"`
+	BranchingFactor  float64 `json:"branching_factor"`
+	SolvabilityScore float64 `json:"solvability_score"`
+}
+
+// Difficulty scores the maze: the length of its optimal solution, how
+// many dead ends it has, the average number of open passages per room,
+// and a solvability score (optimal steps divided by the number of
+// rooms) that's roughly comparable across maze sizes.
+func (m *Maze) Difficulty() MazeStats {
+	var stats MazeStats
+
+	if path, err := m.ShortestPath(m.start, m.end); err == nil {
+		stats.OptimalSteps = len(path) - 1
+	} else {
+		stats.OptimalSteps = -1
+	}
+
+	openings := 0
+	for y := 0; y < m.Height(); y++ {
+		for x := 0; x < m.Width(); x++ {
+			open := 0
+			for _, d := range DIRECTIONS {
+				if canStep(m, x, y, d) {
+					open++
+				}
+			}
+			openings += open
+			if open == 1 {
+				stats.DeadEnds++
+			}
+		}
+	}
+
+	rooms := m.Width() * m.Height()
+	if rooms > 0 {
+		stats.BranchingFactor = float64(openings) / float64(rooms)
+	}
+	if rooms > 0 && stats.OptimalSteps >= 0 {
+		stats.SolvabilityScore = float64(stats.OptimalSteps) / float64(rooms)
+	}
+
+	return stats
+}
+
+// Oracle is a debug-only endpoint (gated by --debug) that lets a solver's
+// step count be compared against the optimal one.
+func Oracle(c *gin.Context) {
+	session, err := sessionMgr.Get(c.GetHeader(SessionHeader))
+	if err != nil {
+		c.JSON(http.StatusNotFound, mazelib.Reply{Error: true, Message: err.Error()})
+		return
+	}
+
+	path, err := session.Maze.ShortestPath(session.Maze.icarus, session.Maze.end)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"optimal_remaining_steps": len(path) - 1,
+		"steps_taken":             session.Maze.StepsTaken(),
+		"stats":                   session.Maze.Difficulty(),
+	})
+}
+
+// solveCmd prints a generated maze with its shortest solution overlaid,
+// similar to the Rosetta Code maze-solving renderings.
+var solveCmd = &cobra.Command{
+	Use:   "solve",
+	Short: "Generate a maze and print its shortest solution",
+	Run: func(cmd *cobra.Command, args []string) {
+		m := createMaze()
+		path, err := m.ShortestPath(m.start, m.end)
+		if err != nil {
+			fmt.Println("This maze has no solution:", err)
+			os.Exit(-1)
+		}
+
+		raw, err := m.toMazeData().MarshalText()
+		if err != nil {
+			fmt.Println("Unable to render maze:", err)
+			os.Exit(-1)
+		}
+
+		lines := strings.Split(strings.TrimRight(string(raw), "\n"), "\n")
+		for _, c := range path {
+			gr, gc := 2*c.Y+1, 2*c.X+1
+			row := []byte(lines[gr])
+			row[gc] = '*'
+			lines[gr] = string(row)
+		}
+
+		fmt.Println(strings.Join(lines, "\n"))
+	},
+}
+
+func init() {
+	// min-difficulty feeds createMaze, which solve (below) and dump both
+	// call, so it needs to be a PersistentFlag to be inherited by them.
+	daedalusCmd.PersistentFlags().Float64("min-difficulty", 0, "regenerate mazes whose solvability score falls below this")
+	viper.BindPFlag("min-difficulty", daedalusCmd.PersistentFlags().Lookup("min-difficulty"))
+
+	daedalusCmd.Flags().Bool("debug", false, "expose the /oracle debug endpoint")
+	viper.BindPFlag("debug", daedalusCmd.Flags().Lookup("debug"))
+
+	daedalusCmd.AddCommand(solveCmd)
+}
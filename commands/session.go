@@ -0,0 +1,221 @@
+// Copyright © 2015 Steve Francia <spf@spf13.com>.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+//
+
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/isaiah/gc6/mazelib"
+)
+
+// SessionHeader is the HTTP header Icarus must send on /move and /done
+// once it has a session token from /awake.
+const SessionHeader = "X-Icarus-Session"
+
+var (
+	// ErrSessionNotFound is returned when a request references a token
+	// the SessionManager doesn't know about (expired, or never existed).
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrTooManySessions is returned by Create when the configured
+	// concurrency limit has already been reached.
+	ErrTooManySessions = errors.New("too many concurrent sessions")
+)
+
+// Session is one client's private view of the labyrinth: its own maze,
+// its own step count, and its own history of completed runs.
+type Session struct {
+	Token      string
+	Maze       *Maze
+	Scores     []int
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+}
+
+// SessionManager replaces the single package-global currentMaze/scores
+// pair with one Maze per client, so multiple Icarus clients can race
+// against independent labyrinths at the same time.
+type SessionManager struct {
+	mu          sync.RWMutex
+	sessions    map[string]*Session
+	maxSessions int
+	timeout     time.Duration
+}
+
+// NewSessionManager returns a SessionManager that allows at most
+// maxSessions concurrent sessions, each expiring after timeout of
+// inactivity. A maxSessions of 0 means unlimited.
+func NewSessionManager(maxSessions int, timeout time.Duration) *SessionManager {
+	return &SessionManager{
+		sessions:    make(map[string]*Session),
+		maxSessions: maxSessions,
+		timeout:     timeout,
+	}
+}
+
+// Create starts a new session with a freshly generated maze and returns
+// it, or ErrTooManySessions if the manager is already at capacity.
+func (sm *SessionManager) Create() (*Session, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.maxSessions > 0 && len(sm.sessions) >= sm.maxSessions {
+		return nil, ErrTooManySessions
+	}
+
+	now := time.Now()
+	s := &Session{
+		Token:      newSessionToken(),
+		Maze:       createMaze(),
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	sm.sessions[s.Token] = s
+	return s, nil
+}
+
+// Get returns the session for token, touching its LastSeenAt so it
+// doesn't expire out from under an active client.
+func (sm *SessionManager) Get(token string) (*Session, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.sessions[token]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	s.LastSeenAt = time.Now()
+	return s, nil
+}
+
+// Complete records a finished run's step count on the session, the same
+// way the old package-global `scores` slice was built up. The session
+// itself stays until Delete (/done) or ExpireStale removes it.
+func (sm *SessionManager) Complete(token string, steps int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if s, ok := sm.sessions[token]; ok {
+		s.Scores = append(s.Scores, steps)
+	}
+}
+
+// Delete removes a session, e.g. when /done is called.
+func (sm *SessionManager) Delete(token string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	delete(sm.sessions, token)
+}
+
+// ExpireStale removes every session that hasn't been touched within the
+// configured timeout. It's meant to be run periodically in the
+// background so abandoned clients don't leak sessions forever.
+func (sm *SessionManager) ExpireStale() {
+	if sm.timeout <= 0 {
+		return
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	cutoff := time.Now().Add(-sm.timeout)
+	for token, s := range sm.sessions {
+		if s.LastSeenAt.Before(cutoff) {
+			delete(sm.sessions, token)
+		}
+	}
+}
+
+// runExpiryLoop periodically calls ExpireStale until stop is closed.
+func (sm *SessionManager) runExpiryLoop(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			sm.ExpireStale()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// SessionStats is the aggregate view returned by the /stats endpoint.
+type SessionStats struct {
+	ActiveSessions int `json:"active_sessions"`
+	TotalCompleted int `json:"total_completed"`
+	AvgSteps       int `json:"avg_steps"`
+}
+
+// Stats summarizes every active session's completed runs.
+func (sm *SessionManager) Stats() SessionStats {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var all []int
+	for _, s := range sm.sessions {
+		all = append(all, s.Scores...)
+	}
+
+	return SessionStats{
+		ActiveSessions: len(sm.sessions),
+		TotalCompleted: len(all),
+		AvgSteps:       mazelib.AvgScores(all),
+	}
+}
+
+// SessionInfo is one session's entry in the /sessions endpoint.
+type SessionInfo struct {
+	Token      string    `json:"token"`
+	StepsTaken int       `json:"steps_taken"`
+	Completed  int       `json:"completed"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// List returns a snapshot of every active session, for the /sessions
+// endpoint.
+func (sm *SessionManager) List() []SessionInfo {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	infos := make([]SessionInfo, 0, len(sm.sessions))
+	for _, s := range sm.sessions {
+		infos = append(infos, SessionInfo{
+			Token:      s.Token,
+			StepsTaken: s.Maze.StepsTaken(),
+			Completed:  len(s.Scores),
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+		})
+	}
+	return infos
+}
+
+// newSessionToken generates a random, URL-safe session identifier.
+func newSessionToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand failing means the system is in bad shape; a
+		// time-derived fallback is still better than handing out
+		// colliding tokens.
+		return hex.EncodeToString([]byte(time.Now().String()))[:32]
+	}
+	return hex.EncodeToString(b)
+}
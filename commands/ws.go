@@ -0,0 +1,189 @@
+// Copyright © 2015 Steve Francia <spf@spf13.com>.
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+//
+
+package commands
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/isaiah/gc6/mazelib"
+	"github.com/spf13/viper"
+)
+
+// wsUpgrader turns a plain HTTP connection into a websocket one. Origin
+// checking is left wide open, same as the rest of this API.
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsCommand is a message a client sends over the /ws connection.
+type wsCommand struct {
+	Cmd string `json:"cmd"`
+	Dir string `json:"dir,omitempty"`
+}
+
+// wsFrame is a message the server pushes back, either in direct reply to
+// a command or unsolicited (Event set, everything else empty).
+type wsFrame struct {
+	Survey  mazelib.Survey `json:"survey,omitempty"`
+	Victory bool           `json:"victory,omitempty"`
+	Steps   int            `json:"steps,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	Event   string         `json:"event,omitempty"`
+}
+
+// wsConn serializes writes to a websocket.Conn, which gorilla/websocket
+// forbids calling concurrently. WS has two writers: the main read/reply
+// loop and the idle-timeout goroutine below. Reads aren't wrapped since
+// only the main loop ever reads.
+type wsConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+func (w *wsConn) WriteJSON(v interface{}) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	return w.conn.WriteJSON(v)
+}
+
+func (w *wsConn) ReadJSON(v interface{}) error {
+	return w.conn.ReadJSON(v)
+}
+
+func (w *wsConn) Close() error {
+	return w.conn.Close()
+}
+
+// WS speaks a compact JSON protocol over a single long-lived connection,
+// avoiding the per-move HTTP round trip MoveDirection pays. A client
+// sends {"cmd":"awake"} or {"cmd":"move","dir":"left"}; the server
+// replies with a wsFrame, plus the unsolicited {"event":"timeout"} frame
+// pushed when the session has gone idle too long. That's the only
+// unsolicited event this protocol pushes; there's no server-side trigger
+// that regenerates a maze out from under a live session.
+func WS(c *gin.Context) {
+	raw, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	conn := &wsConn{conn: raw}
+	defer conn.Close()
+
+	var session *Session
+	var lastActivity int64 // unix nano, written by the main loop, read by the timeout goroutine
+	atomic.StoreInt64(&lastActivity, time.Now().UnixNano())
+	timeout := viper.GetDuration("session-timeout")
+
+	if timeout > 0 {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			t := time.NewTicker(timeout / 4)
+			defer t.Stop()
+			for {
+				select {
+				case <-t.C:
+					last := time.Unix(0, atomic.LoadInt64(&lastActivity))
+					if time.Since(last) > timeout {
+						conn.WriteJSON(wsFrame{Event: "timeout"})
+						conn.Close()
+						return
+					}
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		var cmd wsCommand
+		if err := conn.ReadJSON(&cmd); err != nil {
+			if session != nil {
+				sessionMgr.Delete(session.Token)
+			}
+			return
+		}
+		atomic.StoreInt64(&lastActivity, time.Now().UnixNano())
+
+		switch cmd.Cmd {
+		case "awake":
+			s, err := sessionMgr.Create()
+			if err != nil {
+				conn.WriteJSON(wsFrame{Error: err.Error()})
+				continue
+			}
+			session = s
+
+			survey, err := session.Maze.Discover(session.Maze.Icarus())
+			if err != nil {
+				conn.WriteJSON(wsFrame{Error: err.Error()})
+				continue
+			}
+			conn.WriteJSON(wsFrame{Survey: survey})
+
+		case "move":
+			if session == nil {
+				conn.WriteJSON(wsFrame{Error: "call awake before move"})
+				continue
+			}
+			wsMove(conn, session, cmd.Dir)
+
+		default:
+			conn.WriteJSON(wsFrame{Error: "unknown cmd: " + cmd.Cmd})
+		}
+	}
+}
+
+func wsMove(conn *wsConn, session *Session, dir string) {
+	var err error
+	switch dir {
+	case "left":
+		err = session.Maze.MoveLeft()
+	case "right":
+		err = session.Maze.MoveRight()
+	case "down":
+		err = session.Maze.MoveDown()
+	case "up":
+		err = session.Maze.MoveUp()
+	}
+
+	if err != nil {
+		conn.WriteJSON(wsFrame{Error: err.Error()})
+		return
+	}
+
+	survey, e := session.Maze.LookAround()
+	if e == mazelib.ErrVictory {
+		sessionMgr.Complete(session.Token, session.Maze.StepsTaken())
+		conn.WriteJSON(wsFrame{Victory: true, Steps: session.Maze.StepsTaken()})
+		// The client sends another {"cmd":"awake"} to start its next run,
+		// same as it would over REST; we don't push it automatically so
+		// the request/reply pairing over this connection stays simple.
+		return
+	}
+	if e != nil {
+		conn.WriteJSON(wsFrame{Error: e.Error()})
+		return
+	}
+
+	conn.WriteJSON(wsFrame{Survey: survey, Steps: session.Maze.StepsTaken()})
+}
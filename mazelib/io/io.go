@@ -0,0 +1,236 @@
+// Package io persists mazes to and from disk, either as a compact ASCII
+// grid (handy for hand-editing golden files) or as JSON (handy for
+// tooling). It knows nothing about solving or generating mazes — it just
+// round-trips the wall layout, start, and treasure of a maze described by
+// a MazeData value.
+package io
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/isaiah/gc6/mazelib"
+)
+
+// Wall bits, one per side of a room. A set bit means that side is walled.
+const (
+	WallNorth uint8 = 1 << iota
+	WallEast
+	WallSouth
+	WallWest
+)
+
+// WallChar and PathChar are used when rendering/parsing the ASCII grid
+// format. They default to the '#'/' ' pair used by most maze-solver-go
+// readers, and are exported so callers can use a different convention.
+var (
+	WallChar byte = '#'
+	PathChar byte = ' '
+)
+
+// MazeData is a serializable description of a maze: its dimensions, the
+// walls of every room, and where Icarus starts and the treasure sits.
+type MazeData struct {
+	Width    int
+	Height   int
+	Walls    [][]uint8 // Walls[y][x], indexed by the bit constants above
+	Start    mazelib.Coordinate
+	Treasure mazelib.Coordinate
+}
+
+// mazeDataJSON mirrors MazeData's exported fields so MarshalJSON can
+// delegate to encoding/json without recursing on MazeData itself.
+type mazeDataJSON struct {
+	Width    int                `json:"width"`
+	Height   int                `json:"height"`
+	Walls    [][]uint8          `json:"walls"`
+	Start    mazelib.Coordinate `json:"start"`
+	Treasure mazelib.Coordinate `json:"treasure"`
+}
+
+func (d *MazeData) MarshalJSON() ([]byte, error) {
+	return json.Marshal(mazeDataJSON{
+		Width:    d.Width,
+		Height:   d.Height,
+		Walls:    d.Walls,
+		Start:    d.Start,
+		Treasure: d.Treasure,
+	})
+}
+
+func (d *MazeData) UnmarshalJSON(data []byte) error {
+	var j mazeDataJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	if j.Width < 0 || j.Height < 0 {
+		return errors.New("mazelib/io: negative width or height")
+	}
+	if len(j.Walls) != j.Height {
+		return fmt.Errorf("mazelib/io: walls has %d rows, want height %d", len(j.Walls), j.Height)
+	}
+	for y, row := range j.Walls {
+		if len(row) != j.Width {
+			return fmt.Errorf("mazelib/io: walls row %d has %d columns, want width %d", y, len(row), j.Width)
+		}
+	}
+	if !inBounds(j.Start, j.Width, j.Height) {
+		return fmt.Errorf("mazelib/io: start %+v is outside the %dx%d maze", j.Start, j.Width, j.Height)
+	}
+	if !inBounds(j.Treasure, j.Width, j.Height) {
+		return fmt.Errorf("mazelib/io: treasure %+v is outside the %dx%d maze", j.Treasure, j.Width, j.Height)
+	}
+
+	d.Width, d.Height, d.Walls, d.Start, d.Treasure = j.Width, j.Height, j.Walls, j.Start, j.Treasure
+	return nil
+}
+
+// inBounds reports whether c falls within a width x height grid.
+func inBounds(c mazelib.Coordinate, width, height int) bool {
+	return c.X >= 0 && c.X < width && c.Y >= 0 && c.Y < height
+}
+
+// wallSet reports whether the wall bit is set for room (x, y). Rooms
+// outside the maze are treated as walled.
+func (d *MazeData) wallSet(x, y int, bit uint8) bool {
+	if x < 0 || y < 0 || y >= d.Height || x >= d.Width {
+		return true
+	}
+	return d.Walls[y][x]&bit != 0
+}
+
+// MarshalText renders the maze as a (2*Width+1) x (2*Height+1) ASCII
+// grid: posts and walls use WallChar, rooms and open passages use
+// PathChar, and the start/treasure rooms are marked 'S'/'T'. Only each
+// room's east and south walls are read, and the outer border is always
+// drawn walled, so a wall set on just one side of a passage, or a missing
+// border wall, does not survive a round trip through the text format.
+func (d *MazeData) MarshalText() ([]byte, error) {
+	rows := 2*d.Height + 1
+	cols := 2*d.Width + 1
+	grid := make([][]byte, rows)
+	for r := range grid {
+		grid[r] = bytes.Repeat([]byte{WallChar}, cols)
+	}
+
+	for y := 0; y < d.Height; y++ {
+		for x := 0; x < d.Width; x++ {
+			gr, gc := 2*y+1, 2*x+1
+			grid[gr][gc] = PathChar
+
+			if !d.wallSet(x, y, WallEast) {
+				grid[gr][gc+1] = PathChar
+			}
+			if !d.wallSet(x, y, WallSouth) {
+				grid[gr+1][gc] = PathChar
+			}
+		}
+	}
+
+	if d.Width > 0 && d.Height > 0 {
+		grid[2*d.Start.Y+1][2*d.Start.X+1] = 'S'
+		grid[2*d.Treasure.Y+1][2*d.Treasure.X+1] = 'T'
+	}
+
+	var buf bytes.Buffer
+	for _, row := range grid {
+		buf.Write(row)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalText parses the grid format produced by MarshalText.
+func (d *MazeData) UnmarshalText(text []byte) error {
+	lines := strings.Split(strings.TrimRight(string(text), "\n"), "\n")
+	if len(lines) < 3 || len(lines)%2 == 0 {
+		return errors.New("mazelib/io: malformed maze grid")
+	}
+
+	height := (len(lines) - 1) / 2
+	width := (len(lines[0]) - 1) / 2
+
+	d.Width, d.Height = width, height
+	d.Walls = make([][]uint8, height)
+	for y := range d.Walls {
+		d.Walls[y] = make([]uint8, width)
+		for x := range d.Walls[y] {
+			d.Walls[y][x] = WallNorth | WallEast | WallSouth | WallWest
+		}
+	}
+
+	cell := func(r, c int) byte {
+		if r < 0 || r >= len(lines) || c < 0 || c >= len(lines[r]) {
+			return WallChar
+		}
+		return lines[r][c]
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			gr, gc := 2*y+1, 2*x+1
+
+			switch cell(gr, gc) {
+			case 'S':
+				d.Start = mazelib.Coordinate{X: x, Y: y}
+			case 'T':
+				d.Treasure = mazelib.Coordinate{X: x, Y: y}
+			}
+
+			if x+1 < width && cell(gr, gc+1) != WallChar {
+				d.Walls[y][x] &^= WallEast
+				d.Walls[y][x+1] &^= WallWest
+			}
+			if y+1 < height && cell(gr+1, gc) != WallChar {
+				d.Walls[y][x] &^= WallSouth
+				d.Walls[y+1][x] &^= WallNorth
+			}
+		}
+	}
+
+	return nil
+}
+
+// LoadMaze reads a maze from path, choosing the JSON or text format based
+// on its extension (".json" is JSON, anything else is the ASCII grid).
+func LoadMaze(path string) (*MazeData, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &MazeData{}
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(raw, d)
+	} else {
+		err = d.UnmarshalText(raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// SaveMaze writes a maze to path, choosing the JSON or text format based
+// on its extension, the same way LoadMaze does.
+func SaveMaze(d *MazeData, path string) error {
+	var (
+		raw []byte
+		err error
+	)
+	if filepath.Ext(path) == ".json" {
+		raw, err = json.MarshalIndent(d, "", "  ")
+	} else {
+		raw, err = d.MarshalText()
+	}
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, raw, 0644)
+}
@@ -0,0 +1,138 @@
+package solver
+
+import "github.com/isaiah/gc6/mazelib"
+
+// astarNode is an entry in the A* open set.
+type astarNode struct {
+	pos  mazelib.Coordinate
+	path []int
+	cost int // g(n): steps taken so far
+}
+
+// AStar keeps the same discovered map of walls as BFS, but rather than
+// breadth-first searching for the nearest frontier, it picks the
+// frontier room closest to it by Manhattan distance and A*-searches straight
+// to that one target using Manhattan distance as the heuristic.
+//
+// Icarus only ever sees mazelib.Survey and his own position — never the
+// treasure's coordinates — until the move that lands on it, so there is
+// no signal this solver could use to plan directly to the treasure
+// before then; it explores toward frontiers for the whole run.
+type AStar struct {
+	known knownWalls
+	path  []int
+}
+
+// NewAStar returns an empty A* solver.
+func NewAStar() *AStar {
+	return &AStar{known: make(knownWalls)}
+}
+
+func (a *AStar) NextMove(survey mazelib.Survey, pos mazelib.Coordinate) int {
+	a.known[pos] = survey
+
+	if len(a.path) > 0 {
+		next := a.path[0]
+		if a.known.open(pos, next) {
+			a.path = a.path[1:]
+			return next
+		}
+		a.path = nil
+	}
+
+	a.path = a.planPathToFrontier(pos)
+
+	if len(a.path) == 0 {
+		walled := blocked(survey)
+		for _, d := range directions {
+			if !walled[d] {
+				return d
+			}
+		}
+		return directions[0]
+	}
+
+	next := a.path[0]
+	a.path = a.path[1:]
+	return next
+}
+
+// planPath runs A* over the known map from `from` to `to`, using
+// Manhattan distance as the admissible heuristic.
+func (a *AStar) planPath(from, to mazelib.Coordinate) []int {
+	open := []astarNode{{pos: from}}
+	best := map[mazelib.Coordinate]int{from: 0}
+
+	for len(open) > 0 {
+		// Pick the open node with the lowest f = g + h.
+		bi := 0
+		bf := open[0].cost + manhattan(open[0].pos, to)
+		for i := 1; i < len(open); i++ {
+			f := open[i].cost + manhattan(open[i].pos, to)
+			if f < bf {
+				bi, bf = i, f
+			}
+		}
+		cur := open[bi]
+		open = append(open[:bi], open[bi+1:]...)
+
+		if cur.pos == to {
+			return cur.path
+		}
+
+		for _, d := range directions {
+			if !a.known.open(cur.pos, d) {
+				continue
+			}
+			np := neighbor(cur.pos, d)
+			g := cur.cost + 1
+			if existing, ok := best[np]; ok && existing <= g {
+				continue
+			}
+			best[np] = g
+			path := make([]int, len(cur.path)+1)
+			copy(path, cur.path)
+			path[len(cur.path)] = d
+			open = append(open, astarNode{pos: np, path: path, cost: g})
+		}
+	}
+
+	return nil
+}
+
+// planPathToFrontier picks the known frontier room nearest `from` by
+// Manhattan distance, then A*-searches a path straight to it.
+func (a *AStar) planPathToFrontier(from mazelib.Coordinate) []int {
+	target, ok := a.nearestFrontier(from)
+	if !ok {
+		return nil
+	}
+	return a.planPath(from, target)
+}
+
+// nearestFrontier scans every known room's open sides for an unexplored
+// neighbor, and returns the one closest to `from`. The frontier target is
+// that unexplored cell itself (as BFS plans to), not the known room next
+// to it — otherwise a straight corridor has no frontier room other than
+// `from`, and excluding `from` leaves nothing to plan toward.
+func (a *AStar) nearestFrontier(from mazelib.Coordinate) (mazelib.Coordinate, bool) {
+	best := mazelib.Coordinate{}
+	bestDist := -1
+
+	for pos := range a.known {
+		for _, d := range directions {
+			if !a.known.open(pos, d) {
+				continue
+			}
+			np := neighbor(pos, d)
+			if _, seen := a.known[np]; seen {
+				continue
+			}
+			if dist := manhattan(from, np); bestDist == -1 || dist < bestDist {
+				best, bestDist = np, dist
+			}
+		}
+	}
+
+	return best, bestDist != -1
+}
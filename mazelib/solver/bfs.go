@@ -0,0 +1,128 @@
+package solver
+
+import "github.com/isaiah/gc6/mazelib"
+
+// knownWalls records, for every room Icarus has surveyed, which of its
+// four sides are walled off. Rooms that haven't been surveyed yet simply
+// don't appear in the map.
+type knownWalls map[mazelib.Coordinate]mazelib.Survey
+
+func (k knownWalls) open(from mazelib.Coordinate, d int) bool {
+	s, ok := k[from]
+	if !ok {
+		return false
+	}
+	switch d {
+	case mazelib.N:
+		return !s.Top
+	case mazelib.S:
+		return !s.Bottom
+	case mazelib.E:
+		return !s.Right
+	case mazelib.W:
+		return !s.Left
+	}
+	return false
+}
+
+// BFS is a flood-fill solver: it remembers every wall it has ever seen and
+// always walks toward the nearest room it hasn't surveyed yet, replanning
+// whenever a newly discovered wall invalidates the path it was following.
+type BFS struct {
+	known knownWalls
+	path  []int // remaining directions to reach the current target
+	start mazelib.Coordinate
+}
+
+// NewBFS returns an empty BFS/flood-fill solver.
+func NewBFS() *BFS {
+	return &BFS{known: make(knownWalls)}
+}
+
+func (b *BFS) NextMove(survey mazelib.Survey, pos mazelib.Coordinate) int {
+	b.known[pos] = survey
+
+	if len(b.path) > 0 {
+		next := b.path[0]
+		if b.known.open(pos, next) {
+			b.path = b.path[1:]
+			return next
+		}
+		// Something we hadn't seen before invalidated the plan.
+		b.path = nil
+	}
+
+	b.path = b.planPathToFrontier(pos)
+	if len(b.path) == 0 {
+		// Nothing left to explore; pick the first open direction (in
+		// the fixed `directions` order) so we don't get stuck.
+		walled := blocked(survey)
+		for _, d := range directions {
+			if !walled[d] {
+				return d
+			}
+		}
+		return directions[0]
+	}
+
+	next := b.path[0]
+	b.path = b.path[1:]
+	return next
+}
+
+// planPathToFrontier runs a breadth-first search over rooms whose walls
+// are known, looking for the shortest route to a frontier (see
+// isFrontier): an unsurveyed room, or a known room adjacent to one. It
+// returns the directions to follow, in order.
+func (b *BFS) planPathToFrontier(from mazelib.Coordinate) []int {
+	type node struct {
+		pos  mazelib.Coordinate
+		path []int
+	}
+
+	visited := map[mazelib.Coordinate]bool{from: true}
+	queue := []node{{pos: from}}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		if isFrontier(b.known, cur.pos) && len(cur.path) > 0 {
+			return cur.path
+		}
+
+		for _, d := range directions {
+			if !b.known.open(cur.pos, d) {
+				continue
+			}
+			np := neighbor(cur.pos, d)
+			if visited[np] {
+				continue
+			}
+			visited[np] = true
+			path := make([]int, len(cur.path)+1)
+			copy(path, cur.path)
+			path[len(cur.path)] = d
+			queue = append(queue, node{pos: np, path: path})
+		}
+	}
+
+	return nil
+}
+
+// isFrontier reports whether pos hasn't been surveyed yet, or has at
+// least one open side leading to a room we haven't surveyed yet.
+func isFrontier(known knownWalls, pos mazelib.Coordinate) bool {
+	if _, ok := known[pos]; !ok {
+		return true
+	}
+	for _, d := range directions {
+		if !known.open(pos, d) {
+			continue
+		}
+		if _, seen := known[neighbor(pos, d)]; !seen {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+// Package solver implements pluggable strategies for Icarus to escape a
+// Labyrinth while only ever seeing the walls of the room he currently
+// occupies (a mazelib.Survey). Each strategy keeps whatever state it needs
+// (a heading, a set of visited rooms, a discovered map of walls, ...)
+// between calls to NextMove.
+package solver
+
+import "github.com/isaiah/gc6/mazelib"
+
+// Solver picks the next direction to move given what Icarus can currently
+// see and where he currently is. It returns one of mazelib.N, mazelib.S,
+// mazelib.E or mazelib.W.
+type Solver interface {
+	NextMove(survey mazelib.Survey, pos mazelib.Coordinate) int
+}
+
+// directions lists every direction in a fixed, deterministic order so that
+// solvers which iterate over all four don't depend on map ordering.
+var directions = []int{mazelib.N, mazelib.E, mazelib.S, mazelib.W}
+
+var dx = map[int]int{mazelib.N: 0, mazelib.S: 0, mazelib.E: 1, mazelib.W: -1}
+var dy = map[int]int{mazelib.N: -1, mazelib.S: 1, mazelib.E: 0, mazelib.W: 0}
+
+var opposite = map[int]int{
+	mazelib.N: mazelib.S,
+	mazelib.S: mazelib.N,
+	mazelib.E: mazelib.W,
+	mazelib.W: mazelib.E,
+}
+
+// clockwise and counterClockwise let the wall-follower reason about "turn
+// right" / "turn left" relative to whichever way it is currently facing.
+var clockwise = map[int]int{
+	mazelib.N: mazelib.E,
+	mazelib.E: mazelib.S,
+	mazelib.S: mazelib.W,
+	mazelib.W: mazelib.N,
+}
+
+var counterClockwise = map[int]int{
+	mazelib.N: mazelib.W,
+	mazelib.W: mazelib.S,
+	mazelib.S: mazelib.E,
+	mazelib.E: mazelib.N,
+}
+
+// neighbor returns the coordinate one step away from pos in direction d.
+func neighbor(pos mazelib.Coordinate, d int) mazelib.Coordinate {
+	return mazelib.Coordinate{X: pos.X + dx[d], Y: pos.Y + dy[d]}
+}
+
+// blocked reports, for each direction, whether the survey says there is a
+// wall in that direction from the current room.
+func blocked(survey mazelib.Survey) map[int]bool {
+	return map[int]bool{
+		mazelib.N: survey.Top,
+		mazelib.S: survey.Bottom,
+		mazelib.E: survey.Right,
+		mazelib.W: survey.Left,
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// manhattan returns the Manhattan distance between two coordinates.
+func manhattan(a, b mazelib.Coordinate) int {
+	return abs(a.X-b.X) + abs(a.Y-b.Y)
+}
@@ -0,0 +1,66 @@
+package solver
+
+import "github.com/isaiah/gc6/mazelib"
+
+// Tremaux solves the maze using Tremaux's algorithm: mark every cell
+// visited, prefer unvisited neighbors, and backtrack along the path
+// already taken once every neighbor has either been visited or is
+// walled off.
+type Tremaux struct {
+	visited map[mazelib.Coordinate]int
+	path    []int // directions taken, in order, so we can backtrack
+}
+
+// NewTremaux returns an empty Tremaux solver.
+func NewTremaux() *Tremaux {
+	return &Tremaux{visited: make(map[mazelib.Coordinate]int)}
+}
+
+func (t *Tremaux) NextMove(survey mazelib.Survey, pos mazelib.Coordinate) int {
+	t.visited[pos]++
+	walled := blocked(survey)
+
+	// Find the least-visited open neighbor; an unvisited one (0 visits)
+	// wins outright.
+	best := -1
+	bestVisits := -1
+	for _, d := range directions {
+		if walled[d] {
+			continue
+		}
+		v := t.visited[neighbor(pos, d)]
+		if bestVisits == -1 || v < bestVisits {
+			best, bestVisits = d, v
+		}
+	}
+
+	if best == -1 {
+		// Fully walled in: reverse the last move, or return -1 if
+		// there is none.
+		return t.backtrack()
+	}
+
+	if bestVisits == 0 {
+		t.path = append(t.path, best)
+		return best
+	}
+
+	// No unvisited neighbor: back off the way we came, if we can.
+	if back := t.backtrack(); back != -1 {
+		return back
+	}
+
+	t.path = append(t.path, best)
+	return best
+}
+
+// backtrack pops the last move taken and returns its opposite, or -1 if
+// there is nowhere left to backtrack to.
+func (t *Tremaux) backtrack() int {
+	if len(t.path) == 0 {
+		return -1
+	}
+	last := t.path[len(t.path)-1]
+	t.path = t.path[:len(t.path)-1]
+	return opposite[last]
+}
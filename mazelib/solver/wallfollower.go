@@ -0,0 +1,41 @@
+package solver
+
+import "github.com/isaiah/gc6/mazelib"
+
+// WallFollower solves the maze with the classic right-hand rule: always
+// try to turn right first, then go straight, then left, and only turn
+// around as a last resort. It is guaranteed to find the treasure as long
+// as the maze has no loops (a perfect maze); around a loop it can circle
+// forever. It can also take a very
+// indirect path to get there.
+type WallFollower struct {
+	heading int
+}
+
+// NewWallFollower returns a WallFollower starting out facing North.
+func NewWallFollower() *WallFollower {
+	return &WallFollower{heading: mazelib.N}
+}
+
+func (w *WallFollower) NextMove(survey mazelib.Survey, pos mazelib.Coordinate) int {
+	walled := blocked(survey)
+
+	// Priority order: turn right, go straight, turn left, turn around.
+	candidates := []int{
+		clockwise[w.heading],
+		w.heading,
+		counterClockwise[w.heading],
+		opposite[w.heading],
+	}
+
+	for _, d := range candidates {
+		if !walled[d] {
+			w.heading = d
+			return d
+		}
+	}
+
+	// Every direction is walled. Shouldn't happen in a well formed maze,
+	// but keep facing the same way rather than panicking.
+	return w.heading
+}